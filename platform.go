@@ -13,16 +13,79 @@ type Platform struct {
 	OS   string
 	Arch string
 
+	// Variant is the microarchitecture level for this platform, such as
+	// GOARM=7, GOAMD64=v3, GOMIPS=softfloat, or GOPPC64=power9. It is
+	// empty for platforms that don't have (or don't pin) a variant.
+	Variant string
+
 	// Default, if true, will be included as a default build target
 	// if no OS/arch is specified. We try to only set as a default popular
 	// targets or targets that are generally useful. For example, Android
 	// is not a default because it is quite rare that you're cross-compiling
 	// something to Android AND something like Linux.
 	Default bool
+
+	// OSVersion is the minimum OS version this platform targets, in OCI
+	// image-spec terms (e.g. "10.0.17763" for a Windows nanoserver base
+	// image). It is empty for every OS except Windows, where the kernel
+	// build number is required to select a compatible base image.
+	OSVersion string
 }
 
 func (p *Platform) String() string {
-	return fmt.Sprintf("%s/%s", p.OS, p.Arch)
+	var s string
+	if p.Variant != "" {
+		s = fmt.Sprintf("%s/%s/%s", p.OS, p.Arch, p.Variant)
+	} else {
+		s = fmt.Sprintf("%s/%s", p.OS, p.Arch)
+	}
+	if p.OSVersion != "" {
+		s += ":" + p.OSVersion
+	}
+	return s
+}
+
+// VariantEnv returns the environment variable name and value that the Go
+// toolchain expects in order to select this platform's microarchitecture
+// variant, e.g. ("GOARM", "7"). ok is false if the platform has no variant
+// set or the variant isn't tied to a known env var.
+func (p *Platform) VariantEnv() (key, value string, ok bool) {
+	if p.Variant == "" {
+		return "", "", false
+	}
+
+	av, found := archVariants[p.Arch]
+	if !found {
+		return "", "", false
+	}
+
+	return av.env, p.Variant, true
+}
+
+// Env returns the environment the Go toolchain needs to build this
+// platform, appending to (and not mutating) base: GOOS, GOARCH, and, if
+// this platform pins a microarchitecture variant, the corresponding
+// GOARM/GOAMD64/GOMIPS/GOPPC64 entry. This is what the builder sets
+// before invoking `go build`.
+func (p *Platform) Env(base []string) []string {
+	env := append(append([]string{}, base...), "GOOS="+p.OS, "GOARCH="+p.Arch)
+
+	if key, value, ok := p.VariantEnv(); ok {
+		env = append(env, key+"="+value)
+	}
+
+	return env
+}
+
+// OutputSuffix returns the "_os_arch" (or "_os_arch_variant") suffix the
+// builder appends to output binary names for this platform, so that
+// e.g. linux/arm/5 and linux/arm/7 binaries don't collide.
+func (p *Platform) OutputSuffix() string {
+	suffix := fmt.Sprintf("_%s_%s", p.OS, p.Arch)
+	if p.Variant != "" {
+		suffix += "_" + p.Variant
+	}
+	return suffix
 }
 
 // addDrop appends all of the "add" entries and drops the "drop" entries, ignoring
@@ -62,124 +125,218 @@ func addDrop(base []Platform, add []Platform, drop []Platform) []Platform {
 	return result
 }
 
+// platformToken matches the platforms in supported against a single
+// -platform token: an OS/Arch pair (or OS/Arch/Variant triple) if the
+// token contains a "/", otherwise an OS name or an Arch name, matching
+// either field. Callers are expected to have already rejected tokens with
+// more than two "/"s via validatePlatformToken.
+func platformToken(supported []Platform, token string) []Platform {
+	var result []Platform
+
+	if strings.Contains(token, "/") {
+		parts := strings.Split(token, "/")
+		for _, platform := range supported {
+			if platform.OS != parts[0] || platform.Arch != parts[1] {
+				continue
+			}
+			if len(parts) == 3 && platform.Variant != parts[2] {
+				continue
+			}
+			result = append(result, platform)
+		}
+		return result
+	}
+
+	for _, platform := range supported {
+		if platform.OS == token || platform.Arch == token {
+			result = append(result, platform)
+		}
+	}
+
+	return result
+}
+
+// PlatformBuilder composes a platform list from a left-to-right script of
+// Add, Drop, and Clear operations, as parsed from the -platform flag's
+// mutation string (e.g. "-windows +linux/s390x"). Each operation is
+// recorded and only resolved against a concrete supported/base list when
+// Build is called.
+type PlatformBuilder struct {
+	ops []platformBuilderOp
+}
+
+type platformBuilderOp struct {
+	kind  platformBuilderOpKind
+	token string
+}
+
+type platformBuilderOpKind int
+
+const (
+	platformOpAdd platformBuilderOpKind = iota
+	platformOpDrop
+	platformOpClear
+)
+
+// Add records that the OS, Arch, or OS/Arch pair named by token should be
+// added to the set.
+func (b *PlatformBuilder) Add(token string) {
+	b.ops = append(b.ops, platformBuilderOp{kind: platformOpAdd, token: token})
+}
+
+// Drop records that the OS, Arch, or OS/Arch pair named by token should be
+// removed from the set.
+func (b *PlatformBuilder) Drop(token string) {
+	b.ops = append(b.ops, platformBuilderOp{kind: platformOpDrop, token: token})
+}
+
+// Clear records that the set built up so far should be discarded entirely.
+func (b *PlatformBuilder) Clear() {
+	b.ops = append(b.ops, platformBuilderOp{kind: platformOpClear})
+}
+
+// Build runs the recorded script against base, resolving each Add/Drop
+// token against supported, and returns the resulting platform list.
+func (b *PlatformBuilder) Build(base []Platform, supported []Platform) []Platform {
+	result := base
+
+	for _, op := range b.ops {
+		switch op.kind {
+		case platformOpClear:
+			result = nil
+		case platformOpAdd:
+			result = addDrop(result, platformToken(supported, op.token), nil)
+		case platformOpDrop:
+			result = addDrop(result, nil, platformToken(supported, op.token))
+		}
+	}
+
+	return result
+}
+
+// goVersionLatest is used to expand variants when we don't have an actual
+// Go version to check constraints against (e.g. the version string failed
+// to parse), so we fall back to whatever the newest release supports.
+var goVersionLatest = version.Must(version.NewVersion("1.23"))
+
 var (
 	Platforms_1_0 = []Platform{
-		{"darwin", "386", true},
-		{"darwin", "amd64", true},
-		{"linux", "386", true},
-		{"linux", "amd64", true},
-		{"linux", "arm", true},
-		{"freebsd", "386", true},
-		{"freebsd", "amd64", true},
-		{"openbsd", "386", true},
-		{"openbsd", "amd64", true},
-		{"windows", "386", true},
-		{"windows", "amd64", true},
+		{"darwin", "386", "", true, ""},
+		{"darwin", "amd64", "", true, ""},
+		{"linux", "386", "", true, ""},
+		{"linux", "amd64", "", true, ""},
+		{"linux", "arm", "", true, ""},
+		{"freebsd", "386", "", true, ""},
+		{"freebsd", "amd64", "", true, ""},
+		{"openbsd", "386", "", true, ""},
+		{"openbsd", "amd64", "", true, ""},
+		{"windows", "386", "", true, ""},
+		{"windows", "amd64", "", true, ""},
 	}
 
 	Platforms_1_1 = addDrop(Platforms_1_0, []Platform{
-		{"freebsd", "arm", true},
-		{"netbsd", "386", true},
-		{"netbsd", "amd64", true},
-		{"netbsd", "arm", true},
-		{"plan9", "386", false},
+		{"freebsd", "arm", "", true, ""},
+		{"netbsd", "386", "", true, ""},
+		{"netbsd", "amd64", "", true, ""},
+		{"netbsd", "arm", "", true, ""},
+		{"plan9", "386", "", false, ""},
 	}, nil)
 
 	Platforms_1_3 = addDrop(Platforms_1_1, []Platform{
-		{"dragonfly", "386", false},
-		{"dragonfly", "amd64", false},
-		{"nacl", "amd64", false},
-		{"nacl", "amd64p32", false},
-		{"nacl", "arm", false},
-		{"solaris", "amd64", false},
+		{"dragonfly", "386", "", false, ""},
+		{"dragonfly", "amd64", "", false, ""},
+		{"nacl", "amd64", "", false, ""},
+		{"nacl", "amd64p32", "", false, ""},
+		{"nacl", "arm", "", false, ""},
+		{"solaris", "amd64", "", false, ""},
 	}, nil)
 
 	Platforms_1_4 = addDrop(Platforms_1_3, []Platform{
-		{"android", "arm", false},
-		{"plan9", "amd64", false},
+		{"android", "arm", "", false, ""},
+		{"plan9", "amd64", "", false, ""},
 	}, nil)
 
 	Platforms_1_5 = addDrop(Platforms_1_4, []Platform{
-		{"darwin", "arm", false},
-		{"darwin", "arm64", false},
-		{"linux", "arm64", false},
-		{"linux", "ppc64", false},
-		{"linux", "ppc64le", false},
+		{"darwin", "arm", "", false, ""},
+		{"darwin", "arm64", "", false, ""},
+		{"linux", "arm64", "", false, ""},
+		{"linux", "ppc64", "", false, ""},
+		{"linux", "ppc64le", "", false, ""},
 	}, nil)
 
 	Platforms_1_6 = addDrop(Platforms_1_5, []Platform{
-		{"android", "386", false},
-		{"android", "amd64", false},
-		{"linux", "mips64", false},
-		{"linux", "mips64le", false},
-		{"nacl", "386", false},
-		{"openbsd", "arm", true},
+		{"android", "386", "", false, ""},
+		{"android", "amd64", "", false, ""},
+		{"linux", "mips64", "", false, ""},
+		{"linux", "mips64le", "", false, ""},
+		{"nacl", "386", "", false, ""},
+		{"openbsd", "arm", "", true, ""},
 	}, nil)
 
 	Platforms_1_7 = addDrop(Platforms_1_5, []Platform{
 		// While not fully supported s390x is generally useful
-		{"linux", "s390x", true},
-		{"plan9", "arm", false},
+		{"linux", "s390x", "", true, ""},
+		{"plan9", "arm", "", false, ""},
 		// Add the 1.6 Platforms, but reflect full support for mips64 and mips64le
-		{"android", "386", false},
-		{"android", "amd64", false},
-		{"linux", "mips64", true},
-		{"linux", "mips64le", true},
-		{"nacl", "386", false},
-		{"openbsd", "arm", true},
+		{"android", "386", "", false, ""},
+		{"android", "amd64", "", false, ""},
+		{"linux", "mips64", "", true, ""},
+		{"linux", "mips64le", "", true, ""},
+		{"nacl", "386", "", false, ""},
+		{"openbsd", "arm", "", true, ""},
 	}, nil)
 
 	Platforms_1_8 = addDrop(Platforms_1_7, []Platform{
-		{"linux", "mips", true},
-		{"linux", "mipsle", true},
+		{"linux", "mips", "", true, ""},
+		{"linux", "mipsle", "", true, ""},
 	}, nil)
 
 	// no new platforms in 1.9
 	Platforms_1_9 = Platforms_1_8
 
 	// unannounced, but dropped support for android/amd64
-	Platforms_1_10 = addDrop(Platforms_1_9, nil, []Platform{{"android", "amd64", false}})
+	Platforms_1_10 = addDrop(Platforms_1_9, nil, []Platform{{"android", "amd64", "", false, ""}})
 
 	Platforms_1_11 = addDrop(Platforms_1_10, []Platform{
-		{"js", "wasm", true},
+		{"js", "wasm", "", true, ""},
 	}, nil)
 
 	Platforms_1_12 = addDrop(Platforms_1_11, []Platform{
-		{"aix", "ppc64", false},
-		{"windows", "arm", true},
+		{"aix", "ppc64", "", false, ""},
+		{"windows", "arm", "", true, ""},
 	}, nil)
 
 	Platforms_1_13 = addDrop(Platforms_1_12, []Platform{
-		{"illumos", "amd64", false},
-		{"netbsd", "arm64", true},
-		{"openbsd", "arm64", true},
+		{"illumos", "amd64", "", false, ""},
+		{"netbsd", "arm64", "", true, ""},
+		{"openbsd", "arm64", "", true, ""},
 	}, nil)
 
 	Platforms_1_14 = addDrop(Platforms_1_13, []Platform{
-		{"freebsd", "arm64", true},
-		{"linux", "riscv64", true},
+		{"freebsd", "arm64", "", true, ""},
+		{"linux", "riscv64", "", true, ""},
 	}, []Platform{
 		// drop nacl
-		{"nacl", "386", false},
-		{"nacl", "amd64", false},
-		{"nacl", "arm", false},
+		{"nacl", "386", "", false, ""},
+		{"nacl", "amd64", "", false, ""},
+		{"nacl", "arm", "", false, ""},
 	})
 
 	Platforms_1_15 = addDrop(Platforms_1_14, []Platform{
-		{"android", "arm64", false},
+		{"android", "arm64", "", false, ""},
 	}, []Platform{
 		// drop i386 macos
-		{"darwin", "386", false},
+		{"darwin", "386", "", false, ""},
 	})
 
 	Platforms_1_16 = addDrop(Platforms_1_15, []Platform{
-		{"android", "amd64", false},
-		{"darwin", "arm64", true},
-		{"openbsd", "mips64", false},
+		{"android", "amd64", "", false, ""},
+		{"darwin", "arm64", "", true, ""},
+		{"openbsd", "mips64", "", false, ""},
 	}, nil)
 
 	Platforms_1_17 = addDrop(Platforms_1_16, []Platform{
-		{"windows", "arm64", true},
+		{"windows", "arm64", "", true, ""},
 	}, nil)
 
 	// no new platforms in 1.18
@@ -187,18 +344,18 @@ var (
 
 	// Go 1.19: Added linux/loong64 support
 	Platforms_1_19 = addDrop(Platforms_1_18, []Platform{
-		{"linux", "loong64", true},
+		{"linux", "loong64", "", true, ""},
 	}, nil)
 
 	// Go 1.20: Added freebsd/riscv64 support
 	Platforms_1_20 = addDrop(Platforms_1_19, []Platform{
-		{"freebsd", "riscv64", true},
+		{"freebsd", "riscv64", "", true, ""},
 	}, nil)
 
 	// Go 1.21: Added android/386, android/arm, and windows/arm64 improvements
 	Platforms_1_21 = addDrop(Platforms_1_20, []Platform{
-		{"android", "386", false},
-		{"android", "arm", false},
+		{"android", "386", "", false, ""},
+		{"android", "arm", "", false, ""},
 		// windows/arm64 was already added in 1.17, but improved in 1.21
 	}, nil)
 
@@ -213,12 +370,98 @@ var (
 	PlatformsLatest = Platforms_1_23
 )
 
-// SupportedPlatforms returns the full list of supported platforms for
-// the version of Go that is
-func SupportedPlatforms(v string) []Platform {
+// archVariant describes a single value accepted for a microarchitecture
+// variant (e.g. "7" for GOARM) and the Go version constraint under which
+// the Go toolchain understands that value.
+type archVariant struct {
+	value      string
+	constraint string
+}
+
+// archVariants maps a GOARCH to the env var it reads its variant from and
+// the variant values available, in canonical order. GOOS doesn't matter
+// here: GOARM/GOAMD64/GOMIPS/GOPPC64 are selected purely off GOARCH.
+var archVariants = map[string]struct {
+	env      string
+	variants []archVariant
+}{
+	"arm": {"GOARM", []archVariant{
+		{"5", ">= 1.1"},
+		{"6", ">= 1.1"},
+		{"7", ">= 1.1"},
+	}},
+	"amd64": {"GOAMD64", []archVariant{
+		{"v1", ">= 1.18"},
+		{"v2", ">= 1.18"},
+		{"v3", ">= 1.18"},
+		{"v4", ">= 1.18"},
+	}},
+	"mips": {"GOMIPS", []archVariant{
+		{"hardfloat", ">= 1.8"},
+		{"softfloat", ">= 1.8"},
+	}},
+	"mipsle": {"GOMIPS", []archVariant{
+		{"hardfloat", ">= 1.8"},
+		{"softfloat", ">= 1.8"},
+	}},
+	"ppc64": {"GOPPC64", []archVariant{
+		{"power8", ">= 1.9"},
+		{"power9", ">= 1.12"},
+		{"power10", ">= 1.18"},
+	}},
+	"ppc64le": {"GOPPC64", []archVariant{
+		{"power8", ">= 1.9"},
+		{"power9", ">= 1.12"},
+		{"power10", ">= 1.18"},
+	}},
+}
+
+// expandVariants adds, alongside each platform whose GOARCH has known
+// microarchitecture variants, one extra entry per variant value supported
+// by the given Go version, so that e.g. "linux/arm" also yields
+// "linux/arm/5", "linux/arm/6", and "linux/arm/7". The original
+// non-variant entry is always kept as-is (so it stays a default build
+// target if it was one); the synthesized per-variant entries are never
+// default themselves, since pinning a variant is something a caller must
+// ask for explicitly via -osarch or -platform. Platforms with no known
+// variants, or that already pin one, are passed through unchanged.
+func expandVariants(plats []Platform, current *version.Version) []Platform {
+	result := make([]Platform, 0, len(plats))
+
+	for _, p := range plats {
+		result = append(result, p)
+
+		av, ok := archVariants[p.Arch]
+		if !ok || p.Variant != "" {
+			continue
+		}
+
+		for _, variant := range av.variants {
+			constraints, err := version.NewConstraint(variant.constraint)
+			if err != nil {
+				panic(err)
+			}
+			if !constraints.Check(current) {
+				continue
+			}
+
+			expanded := p
+			expanded.Variant = variant.value
+			expanded.Default = false
+			result = append(result, expanded)
+		}
+	}
+
+	return result
+}
+
+// parseGoVersion parses a "goX.Y[.Z]" version string, such as what
+// `go version` reports, falling back to goVersionLatest if v doesn't look
+// like one or fails to parse.
+func parseGoVersion(v string) *version.Version {
 	// Use latest if we get an unexpected version string
 	if !strings.HasPrefix(v, "go") {
-		return PlatformsLatest
+		return goVersionLatest
 	}
 	// go-version only cares about version numbers
 	v = v[2:]
@@ -228,7 +471,42 @@ func SupportedPlatforms(v string) []Platform {
 		log.Printf("Unable to parse current go version: %s\n%s", v, err.Error())
 
 		// Default to latest
-		return PlatformsLatest
+		return goVersionLatest
+	}
+
+	return current
+}
+
+// ForceStaticPlatforms, when true, makes SupportedPlatforms skip local
+// toolchain detection and always use the baked-in Platforms_* tables
+// above. This is the escape hatch for a -static-platforms style flag, for
+// environments where shelling out to the Go toolchain isn't desirable.
+var ForceStaticPlatforms bool
+
+// SupportedPlatforms returns the full list of supported platforms for
+// the version of Go reported by v (e.g. "go1.21.0", such as from running
+// `go version` on the toolchain gox is about to build with).
+//
+// When goToolCmd is runnable and its own reported version matches v —
+// i.e. v actually refers to goToolCmd, which is the normal case since gox
+// derives v by running goToolCmd itself — this prefers asking that
+// toolchain directly via DetectSupportedPlatforms over the baked-in
+// Platforms_* tables above, so newly added ports don't have to wait for a
+// gox release. Note this is independent of the Go version gox itself was
+// built with. For any other v, detection would describe the wrong
+// toolchain's capabilities, so v is used to select from the tables
+// instead, exactly as before. Both the version check and the detection
+// call are memoized, so goToolCmd is invoked at most once each per
+// process no matter how many times this is called.
+func SupportedPlatforms(v string) []Platform {
+	current := parseGoVersion(v)
+
+	if !ForceStaticPlatforms {
+		if goVer, err := goCmdVersionCached(goToolCmd); err == nil && goVer == v {
+			if detected, err := detectSupportedPlatformsCached(goToolCmd); err == nil {
+				return expandVariants(detected, parseGoVersion(goVer))
+			}
+		}
 	}
 
 	var platforms = []struct {
@@ -266,10 +544,10 @@ func SupportedPlatforms(v string) []Platform {
 			panic(err)
 		}
 		if constraints.Check(current) {
-			return p.plat
+			return expandVariants(p.plat, current)
 		}
 	}
 
 	// Assume latest
-	return PlatformsLatest
+	return expandVariants(PlatformsLatest, goVersionLatest)
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// goToolCmd is the name of the Go binary SupportedPlatforms shells out to
+// when attempting toolchain detection. It's a var, rather than a
+// hardcoded "go", so tests can point it at a fixture-backed stand-in.
+var goToolCmd = "go"
+
+var (
+	detectOnce   sync.Once
+	detectResult []Platform
+	detectErr    error
+)
+
+// detectSupportedPlatformsCached calls DetectSupportedPlatforms(goCmd) at
+// most once per process, caching the result (or error) for subsequent
+// calls. SupportedPlatforms may be called many times in a single gox
+// invocation; there's no need to re-exec the toolchain for each one.
+func detectSupportedPlatformsCached(goCmd string) ([]Platform, error) {
+	detectOnce.Do(func() {
+		detectResult, detectErr = DetectSupportedPlatforms(goCmd)
+	})
+	return detectResult, detectErr
+}
+
+var (
+	goCmdVersionOnce   sync.Once
+	goCmdVersionResult string
+	goCmdVersionErr    error
+)
+
+// goCmdVersionCached calls goCmdVersion(goCmd) at most once per process,
+// caching the result (or error) for subsequent calls, for the same reason
+// detectSupportedPlatformsCached does.
+func goCmdVersionCached(goCmd string) (string, error) {
+	goCmdVersionOnce.Do(func() {
+		goCmdVersionResult, goCmdVersionErr = goCmdVersion(goCmd)
+	})
+	return goCmdVersionResult, goCmdVersionErr
+}
+
+// goCmdVersion runs `goCmd version` and extracts the "goX.Y.Z" token from
+// output like "go version go1.21.6 linux/amd64", so callers can tell
+// whether a version string they have (e.g. from that same invocation)
+// actually refers to goCmd.
+func goCmdVersion(goCmd string) (string, error) {
+	out, err := exec.Command(goCmd, "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("running %q version: %w", goCmd, err)
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "go") {
+		return "", fmt.Errorf("unexpected %q version output: %s", goCmd, out)
+	}
+
+	return fields[2], nil
+}
+
+// distListEntry mirrors one row of `go tool dist list -json` output.
+type distListEntry struct {
+	GOOS         string
+	GOARCH       string
+	CgoSupported bool
+	FirstClass   bool
+}
+
+// DetectSupportedPlatforms shells out to `goCmd tool dist list -json` and
+// parses the result into Platforms, using FirstClass to set Default. This
+// lets gox target whatever the locally installed toolchain actually
+// supports, including ports added after gox's own baked-in Platforms_*
+// tables were last updated. It returns an error if goCmd can't be run or
+// its output can't be parsed, so callers can fall back to those tables.
+func DetectSupportedPlatforms(goCmd string) ([]Platform, error) {
+	out, err := exec.Command(goCmd, "tool", "dist", "list", "-json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %q tool dist list -json: %w", goCmd, err)
+	}
+
+	var entries []distListEntry
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %q tool dist list -json output: %w", goCmd, err)
+	}
+
+	platforms := make([]Platform, 0, len(entries))
+	for _, e := range entries {
+		platforms = append(platforms, Platform{
+			OS:      e.GOOS,
+			Arch:    e.GOARCH,
+			Default: e.FirstClass,
+		})
+	}
+
+	return platforms, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// distListFixture is a recorded (trimmed) sample of `go tool dist list
+// -json` output, used to test DetectSupportedPlatforms without depending
+// on the Go version actually installed in the test environment.
+const distListFixture = `[
+  {"GOOS":"linux","GOARCH":"amd64","CgoSupported":true,"FirstClass":true},
+  {"GOOS":"linux","GOARCH":"riscv64","CgoSupported":true,"FirstClass":false},
+  {"GOOS":"windows","GOARCH":"arm64","CgoSupported":false,"FirstClass":false}
+]`
+
+// fixtureGoVersion is the version string writeFixtureGoCmd's script
+// reports itself as when invoked as "goCmd version", standing in for the
+// toolchain distListFixture is pretending to have come from.
+const fixtureGoVersion = "go1.99.0"
+
+// writeFixtureGoCmd writes a fake "go" script that answers "version" with
+// fixtureGoVersion (in the same format `go version` prints) and answers
+// any other invocation (i.e. "tool dist list -json") with output, and
+// returns its path for use as DetectSupportedPlatforms' goCmd argument
+// (or a goToolCmd override) in tests.
+func writeFixtureGoCmd(t *testing.T, output string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fakego.sh")
+	script := `#!/bin/sh
+if [ "$1" = "version" ]; then
+  echo "go version ` + fixtureGoVersion + ` linux/amd64"
+  exit 0
+fi
+cat <<'EOF'
+` + output + `
+EOF
+`
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("writing fixture script: %v", err)
+	}
+	return path
+}
+
+func resetDetectCache() {
+	detectOnce = sync.Once{}
+	detectResult = nil
+	detectErr = nil
+
+	goCmdVersionOnce = sync.Once{}
+	goCmdVersionResult = ""
+	goCmdVersionErr = nil
+}
+
+func TestDetectSupportedPlatforms(t *testing.T) {
+	goCmd := writeFixtureGoCmd(t, distListFixture)
+
+	platforms, err := DetectSupportedPlatforms(goCmd)
+	if err != nil {
+		t.Fatalf("DetectSupportedPlatforms returned error: %v", err)
+	}
+
+	want := []Platform{
+		{OS: "linux", Arch: "amd64", Default: true},
+		{OS: "linux", Arch: "riscv64", Default: false},
+		{OS: "windows", Arch: "arm64", Default: false},
+	}
+
+	if len(platforms) != len(want) {
+		t.Fatalf("DetectSupportedPlatforms() = %+v, want %+v", platforms, want)
+	}
+	for i := range platforms {
+		if platforms[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, platforms[i], want[i])
+		}
+	}
+}
+
+func TestDetectSupportedPlatformsInvalidCommand(t *testing.T) {
+	if _, err := DetectSupportedPlatforms("definitely-not-a-real-go-binary"); err == nil {
+		t.Error("expected an error for a nonexistent go command")
+	}
+}
+
+func TestGoCmdVersion(t *testing.T) {
+	goCmd := writeFixtureGoCmd(t, distListFixture)
+
+	got, err := goCmdVersion(goCmd)
+	if err != nil {
+		t.Fatalf("goCmdVersion returned error: %v", err)
+	}
+	if got != fixtureGoVersion {
+		t.Errorf("goCmdVersion() = %q, want %q", got, fixtureGoVersion)
+	}
+}
+
+func TestSupportedPlatformsPrefersDetectionForRunningToolchain(t *testing.T) {
+	resetDetectCache()
+	defer resetDetectCache()
+
+	ForceStaticPlatforms = false
+
+	origCmd := goToolCmd
+	defer func() { goToolCmd = origCmd }()
+	goToolCmd = writeFixtureGoCmd(t, distListFixture)
+
+	// v matches what goToolCmd itself reports, so detection should kick
+	// in regardless of what Go version compiled this gox binary.
+	got := SupportedPlatforms(fixtureGoVersion)
+
+	foundRiscv := false
+	for _, p := range got {
+		if p.OS == "linux" && p.Arch == "riscv64" {
+			foundRiscv = true
+		}
+	}
+	if !foundRiscv {
+		t.Errorf("SupportedPlatforms(%q) = %+v, want it to include the detected linux/riscv64 entry", fixtureGoVersion, got)
+	}
+}
+
+func TestSupportedPlatformsIgnoresDetectionForOtherVersions(t *testing.T) {
+	resetDetectCache()
+	defer resetDetectCache()
+
+	ForceStaticPlatforms = false
+
+	origCmd := goToolCmd
+	defer func() { goToolCmd = origCmd }()
+	goToolCmd = writeFixtureGoCmd(t, distListFixture)
+
+	// go1.5 predates riscv64 entirely; detection must not leak the
+	// locally-installed toolchain's modern port list into an older,
+	// explicitly requested Go version.
+	got := SupportedPlatforms("go1.5")
+
+	for _, p := range got {
+		if p.OS == "linux" && p.Arch == "riscv64" {
+			t.Errorf(`SupportedPlatforms("go1.5") = %+v, should not include detected linux/riscv64 (added in 1.14)`, got)
+		}
+	}
+}
+
+func TestSupportedPlatformsForceStatic(t *testing.T) {
+	resetDetectCache()
+	defer resetDetectCache()
+
+	origCmd := goToolCmd
+	defer func() { goToolCmd = origCmd }()
+	goToolCmd = writeFixtureGoCmd(t, distListFixture)
+
+	ForceStaticPlatforms = true
+	defer func() { ForceStaticPlatforms = false }()
+
+	got := SupportedPlatforms(fixtureGoVersion)
+
+	// The fixture yields 7 entries once linux/amd64's 4 GOAMD64 variants
+	// are expanded; the baked-in tables have many more, so forcing static
+	// mode must bypass detection entirely.
+	if len(got) <= 7 {
+		t.Errorf("ForceStaticPlatforms = true should bypass the fixture and use the baked-in tables, got %d platforms", len(got))
+	}
+}
@@ -9,9 +9,10 @@ import (
 // PlatformFlag is a flag.Value (and flag.Getter) implementation that
 // is used to track the os/arch flags on the command-line.
 type PlatformFlag struct {
-	OS     []string
-	Arch   []string
-	OSArch []Platform
+	OS       []string
+	Arch     []string
+	OSArch   []Platform
+	Platform PlatformBuilder
 }
 
 // Platforms returns the list of platforms that were set by this flag.
@@ -22,8 +23,6 @@ func (p *PlatformFlag) Platforms(supported []Platform) []Platform {
 	includeArch := make(map[string]bool)
 	ignoreOS := make(map[string]bool)
 	includeOS := make(map[string]bool)
-	ignoreOSArch := make(map[string]bool)
-	includeOSArch := make(map[string]bool)
 
 	// Parse arch flags
 	for _, v := range p.Arch {
@@ -43,20 +42,25 @@ func (p *PlatformFlag) Platforms(supported []Platform) []Platform {
 		}
 	}
 
-	// Parse OS/Arch pairs
+	// Parse OS/Arch(/Variant) specifiers. A specifier with no variant
+	// matches all variants of that OS/Arch; one with a variant only
+	// matches that exact variant.
+	var includeOSArch, ignoreOSArch []Platform
 	for _, v := range p.OSArch {
-		if v.OS[0] == '!' {
-			platform := Platform{OS: v.OS[1:], Arch: v.Arch}
-			ignoreOSArch[platform.String()] = true
+		if strings.HasPrefix(v.OS, "!") {
+			ignoreOSArch = append(ignoreOSArch, Platform{OS: v.OS[1:], Arch: v.Arch, Variant: v.Variant})
 		} else {
-			includeOSArch[v.String()] = true
+			includeOSArch = append(includeOSArch, v)
 		}
 	}
 
-	// Create a map of supported platforms for fast lookup
-	supportedMap := make(map[string]Platform, len(supported))
-	for _, platform := range supported {
-		supportedMap[platform.String()] = platform
+	// matchesSpec reports whether platform satisfies an OS/Arch(/Variant)
+	// specifier, treating an unset specifier variant as "any variant".
+	matchesSpec := func(platform, spec Platform) bool {
+		if platform.OS != spec.OS || platform.Arch != spec.Arch {
+			return false
+		}
+		return spec.Variant == "" || platform.Variant == spec.Variant
 	}
 
 	// Determine which platforms to build
@@ -64,21 +68,21 @@ func (p *PlatformFlag) Platforms(supported []Platform) []Platform {
 
 	// If specific OS/Arch pairs are specified, use those
 	if len(includeOSArch) > 0 {
-		for platformStr := range includeOSArch {
-			if platform, exists := supportedMap[platformStr]; exists && !ignoreOSArch[platformStr] {
-				platform.Default = false
-				result = append(result, platform)
+		for _, platform := range supported {
+			for _, spec := range includeOSArch {
+				if matchesSpec(platform, spec) {
+					platform.Default = false
+					result = append(result, platform)
+					break
+				}
 			}
 		}
 	} else if len(includeOS) > 0 && len(includeArch) > 0 {
 		// Build combinations of specified OS and Arch
-		for os := range includeOS {
-			for arch := range includeArch {
-				platform := Platform{OS: os, Arch: arch}
-				if _, exists := supportedMap[platform.String()]; exists {
-					platform.Default = false
-					result = append(result, platform)
-				}
+		for _, platform := range supported {
+			if includeOS[platform.OS] && includeArch[platform.Arch] {
+				platform.Default = false
+				result = append(result, platform)
 			}
 		}
 	} else if len(includeOS) > 0 {
@@ -101,13 +105,35 @@ func (p *PlatformFlag) Platforms(supported []Platform) []Platform {
 		}
 	}
 
+	// With -arch but neither -os nor -osarch, the default set selected
+	// above is narrowed to the requested arches.
+	if len(includeOSArch) == 0 && len(includeOS) == 0 && len(includeArch) > 0 {
+		narrowed := make([]Platform, 0, len(result))
+		for _, platform := range result {
+			if includeArch[platform.Arch] {
+				narrowed = append(narrowed, platform)
+			}
+		}
+		result = narrowed
+	}
+
+	// Apply the -platform mutation script, if any, on top of whatever
+	// -os/-arch/-osarch selected above (or the default set, if none of
+	// those were given).
+	result = p.Platform.Build(result, supported)
+
 	// Apply exclusion filters
 	filteredResult := make([]Platform, 0, len(result))
 	for _, platform := range result {
-		platformStr := platform.String()
-
 		// Skip if explicitly excluded via OS/Arch pair
-		if ignoreOSArch[platformStr] {
+		excluded := false
+		for _, spec := range ignoreOSArch {
+			if matchesSpec(platform, spec) {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
 			continue
 		}
 
@@ -116,13 +142,11 @@ func (p *PlatformFlag) Platforms(supported []Platform) []Platform {
 			continue
 		}
 
-		// Skip if not included via individual OS or Arch (when no OS/Arch pairs specified)
-		if len(includeOSArch) == 0 && len(includeOS) > 0 && !includeOS[platform.OS] {
-			continue
-		}
-		if len(includeOSArch) == 0 && len(includeArch) > 0 && !includeArch[platform.Arch] {
-			continue
-		}
+		// NOTE: we deliberately don't re-check includeOS/includeArch here.
+		// The selection above already only produces platforms matching
+		// them, and re-checking would also strip out anything the
+		// -platform script just added (e.g. -os=linux -platform="+windows"
+		// should keep the windows entry the script explicitly asked for).
 
 		filteredResult = append(filteredResult, platform)
 	}
@@ -148,9 +172,71 @@ func (p *PlatformFlag) OSArchFlagValue() flag.Value {
 	return (*appendPlatformValue)(&p.OSArch)
 }
 
-// appendPlatformValue is a flag.Value that appends a full platform (os/arch)
-// to a list where the values from space-separated lines. This is used to
-// satisfy the -osarch flag.
+// PlatformFlagValue returns a flag.Value that can be used with the flag
+// package to parse a -platform mutation string into the flag's
+// PlatformBuilder script.
+func (p *PlatformFlag) PlatformFlagValue() flag.Value {
+	return (*platformScriptValue)(&p.Platform)
+}
+
+// platformScriptValue is a flag.Value that parses a left-to-right,
+// space-separated sequence of "+token"/"-token" mutations (and a bare "-"
+// to clear) into a PlatformBuilder script. This is used to satisfy the
+// -platform flag.
+type platformScriptValue PlatformBuilder
+
+func (s *platformScriptValue) String() string {
+	return ""
+}
+
+func (s *platformScriptValue) Set(value string) error {
+	b := (*PlatformBuilder)(s)
+
+	for _, tok := range strings.Fields(value) {
+		switch tok[0] {
+		case '+':
+			t := strings.ToLower(tok[1:])
+			if t == "" {
+				return fmt.Errorf("Invalid platform token: %s", tok)
+			}
+			if err := validatePlatformToken(t); err != nil {
+				return err
+			}
+			b.Add(t)
+		case '-':
+			t := strings.ToLower(tok[1:])
+			if t == "" {
+				b.Clear()
+				continue
+			}
+			if err := validatePlatformToken(t); err != nil {
+				return err
+			}
+			b.Drop(t)
+		default:
+			return fmt.Errorf(
+				"Invalid platform token: %s should start with + or -", tok)
+		}
+	}
+
+	return nil
+}
+
+// validatePlatformToken rejects an add/drop target with more than an
+// os/arch/variant worth of "/"-separated segments, so a malformed token
+// like "linux/arm/7/extra" fails loudly instead of silently matching no
+// platforms in platformToken.
+func validatePlatformToken(t string) error {
+	if strings.Count(t, "/") > 2 {
+		return fmt.Errorf(
+			"Invalid platform token: %s should be os, arch, os/arch, or os/arch/variant", t)
+	}
+	return nil
+}
+
+// appendPlatformValue is a flag.Value that appends a full platform
+// (os/arch or os/arch/variant) to a list where the values from
+// space-separated lines. This is used to satisfy the -osarch flag.
 type appendPlatformValue []Platform
 
 func (s *appendPlatformValue) String() string {
@@ -164,15 +250,18 @@ func (s *appendPlatformValue) Set(value string) error {
 
 	for _, v := range strings.Split(value, " ") {
 		parts := strings.Split(v, "/")
-		if len(parts) != 2 {
+		if len(parts) != 2 && len(parts) != 3 {
 			return fmt.Errorf(
-				"Invalid platform syntax: %s should be os/arch", v)
+				"Invalid platform syntax: %s should be os/arch or os/arch/variant", v)
 		}
 
 		platform := Platform{
 			OS:   strings.ToLower(parts[0]),
 			Arch: strings.ToLower(parts[1]),
 		}
+		if len(parts) == 3 {
+			platform.Variant = strings.ToLower(parts[2])
+		}
 
 		s.appendIfMissing(&platform)
 	}
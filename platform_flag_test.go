@@ -0,0 +1,135 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func platformStrings(plats []Platform) []string {
+	var out []string
+	for _, p := range plats {
+		out = append(out, p.String())
+	}
+	sort.Strings(out)
+	return out
+}
+
+func testSupported() []Platform {
+	return []Platform{
+		{OS: "linux", Arch: "amd64", Default: true},
+		{OS: "linux", Arch: "arm", Variant: "7", Default: false},
+		{OS: "darwin", Arch: "amd64", Default: true},
+		{OS: "windows", Arch: "amd64", Default: false},
+		{OS: "linux", Arch: "s390x", Default: false},
+	}
+}
+
+func TestAppendPlatformValueSet(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		expected []Platform
+	}{
+		{"two segment", "linux/amd64", []Platform{{OS: "linux", Arch: "amd64"}}},
+		{"three segment variant", "linux/arm/7", []Platform{{OS: "linux", Arch: "arm", Variant: "7"}}},
+		{"exclusion", "!linux/amd64", []Platform{{OS: "!linux", Arch: "amd64"}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var v appendPlatformValue
+			if err := v.Set(c.value); err != nil {
+				t.Fatalf("Set(%q) returned error: %v", c.value, err)
+			}
+			if len(v) != len(c.expected) || v[0] != c.expected[0] {
+				t.Errorf("Set(%q) = %+v, want %+v", c.value, []Platform(v), c.expected)
+			}
+		})
+	}
+
+	var bad appendPlatformValue
+	if err := bad.Set("linux/arm/7/extra"); err == nil {
+		t.Error("Set() with 4 segments should return an error")
+	}
+}
+
+func TestPlatformScriptValueTokenForms(t *testing.T) {
+	cases := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		{"add os", "+windows", false},
+		{"drop os", "-windows", false},
+		{"add arch", "+arm64", false},
+		{"drop arch", "-arm64", false},
+		{"add pair", "+linux/s390x", false},
+		{"drop pair", "-linux/s390x", false},
+		{"add variant triple", "+linux/arm/7", false},
+		{"bare clear", "-", false},
+		{"clear then add", "- +linux +darwin", false},
+		{"missing sign", "windows", true},
+		{"empty add target", "+", true},
+		{"too many segments", "+linux/arm/7/extra", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var b PlatformBuilder
+			v := (*platformScriptValue)(&b)
+			err := v.Set(c.script)
+			if c.wantErr && err == nil {
+				t.Errorf("Set(%q) expected an error, got nil", c.script)
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("Set(%q) returned unexpected error: %v", c.script, err)
+			}
+		})
+	}
+}
+
+func TestPlatformFlagPlatformMutation(t *testing.T) {
+	supported := testSupported()
+
+	flag := &PlatformFlag{}
+	script := (*platformScriptValue)(&flag.Platform)
+	if err := script.Set("- +linux/amd64 +darwin"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+
+	got := platformStrings(flag.Platforms(supported))
+	want := []string{"darwin/amd64", "linux/amd64"}
+	sort.Strings(want)
+
+	if len(got) != len(want) {
+		t.Fatalf("Platforms() = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Platforms() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPlatformFlagPlatformMutationCoexistsWithOS(t *testing.T) {
+	supported := testSupported()
+
+	flag := &PlatformFlag{}
+	flag.OS = []string{"linux"}
+	script := (*platformScriptValue)(&flag.Platform)
+	if err := script.Set("+windows/amd64"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+
+	got := platformStrings(flag.Platforms(supported))
+
+	found := false
+	for _, p := range got {
+		if p == "windows/amd64" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Platforms() = %v, want it to include windows/amd64 added via -platform despite -os=linux", got)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ociArchAliases maps alternate architecture spellings, as seen in OCI
+// image platform specifiers, to their canonical Go GOARCH value and any
+// variant implied by the alias (e.g. "armhf" implies GOARM=7).
+var ociArchAliases = map[string]struct {
+	arch    string
+	variant string
+}{
+	"aarch64": {"arm64", ""},
+	"x86_64":  {"amd64", ""},
+	"armhf":   {"arm", "7"},
+}
+
+// ParsePlatform parses an OCI-style platform specifier of the form
+// "os[/arch[/variant]][:osversion]", such as "linux/arm64/v8" or
+// "windows/amd64:10.0.17763". Known arch aliases (aarch64, x86_64, armhf)
+// are normalized to their canonical Go GOARCH value. A specifier may omit
+// the os/arch portion entirely (e.g. ":10.0.17763") to describe an
+// osversion-only constraint.
+func ParsePlatform(spec string) (Platform, error) {
+	osArch, osVersion, hasVersion := strings.Cut(spec, ":")
+
+	if osArch == "" && !hasVersion {
+		return Platform{}, fmt.Errorf(
+			"Invalid platform specifier: %s should be os[/arch[/variant]][:osversion]", spec)
+	}
+
+	// A ":" was present, so an osversion was promised: it must be a single
+	// non-empty token, not empty (":") or itself colon-delimited ("::").
+	if hasVersion && (osVersion == "" || strings.Contains(osVersion, ":")) {
+		return Platform{}, fmt.Errorf(
+			"Invalid platform specifier: %s should be os[/arch[/variant]][:osversion]", spec)
+	}
+
+	var p Platform
+	p.OSVersion = osVersion
+
+	if osArch != "" {
+		parts := strings.Split(osArch, "/")
+		if len(parts) > 3 {
+			return Platform{}, fmt.Errorf(
+				"Invalid platform specifier: %s should be os[/arch[/variant]][:osversion]", spec)
+		}
+		for _, part := range parts {
+			if part == "" {
+				return Platform{}, fmt.Errorf(
+					"Invalid platform specifier: %s should be os[/arch[/variant]][:osversion]", spec)
+			}
+		}
+
+		p.OS = strings.ToLower(parts[0])
+
+		if len(parts) >= 2 {
+			arch := strings.ToLower(parts[1])
+			if alias, ok := ociArchAliases[arch]; ok {
+				p.Arch = alias.arch
+				p.Variant = alias.variant
+			} else {
+				p.Arch = arch
+			}
+		}
+
+		if len(parts) == 3 {
+			p.Variant = strings.ToLower(parts[2])
+		}
+	}
+
+	return p, nil
+}
+
+// Matcher reports whether a Platform satisfies some selection criteria. It
+// is used to filter SupportedPlatforms output or to select a base image
+// manifest entry when publishing OCI images.
+type Matcher interface {
+	Match(p Platform) bool
+}
+
+// specMatcher matches against a (possibly partial) Platform: a field left
+// empty in spec matches any value for that field in the candidate, which
+// is what allows os-only or os+arch specifiers to match broadly.
+type specMatcher struct {
+	spec Platform
+}
+
+func (m specMatcher) Match(p Platform) bool {
+	if m.spec.OS != "" && m.spec.OS != p.OS {
+		return false
+	}
+	if m.spec.Arch != "" && m.spec.Arch != p.Arch {
+		return false
+	}
+	if m.spec.Variant != "" && m.spec.Variant != p.Variant {
+		return false
+	}
+	if m.spec.OSVersion != "" && m.spec.OSVersion != p.OSVersion {
+		return false
+	}
+	return true
+}
+
+// NewMatcher parses spec as an OCI platform specifier and returns a
+// Matcher for it. A partial specifier (os-only, os+arch, or an
+// osversion-only specifier like ":10.0.17763") matches any Platform that
+// agrees on the fields spec actually sets.
+func NewMatcher(spec string) (Matcher, error) {
+	p, err := ParsePlatform(spec)
+	if err != nil {
+		return nil, err
+	}
+	return specMatcher{spec: p}, nil
+}
+
+// Less provides a deterministic ordering over Platforms: by OS, then Arch,
+// then Variant, then OSVersion. It's useful for producing stable output
+// when listing or serializing a platform set, such as an OCI image index.
+func Less(a, b Platform) bool {
+	if a.OS != b.OS {
+		return a.OS < b.OS
+	}
+	if a.Arch != b.Arch {
+		return a.Arch < b.Arch
+	}
+	if a.Variant != b.Variant {
+		return a.Variant < b.Variant
+	}
+	return a.OSVersion < b.OSVersion
+}
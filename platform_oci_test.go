@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected Platform
+	}{
+		{"linux/amd64", Platform{OS: "linux", Arch: "amd64"}},
+		{"linux/arm64/v8", Platform{OS: "linux", Arch: "arm64", Variant: "v8"}},
+		{"windows/amd64:10.0.17763", Platform{OS: "windows", Arch: "amd64", OSVersion: "10.0.17763"}},
+		{"linux/aarch64", Platform{OS: "linux", Arch: "arm64"}},
+		{"linux/x86_64", Platform{OS: "linux", Arch: "amd64"}},
+		{"linux/armhf", Platform{OS: "linux", Arch: "arm", Variant: "7"}},
+		{"linux", Platform{OS: "linux"}},
+		{":10.0.17763", Platform{OSVersion: "10.0.17763"}},
+	}
+
+	for _, c := range cases {
+		got, err := ParsePlatform(c.spec)
+		if err != nil {
+			t.Errorf("ParsePlatform(%q) returned error: %v", c.spec, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("ParsePlatform(%q) = %+v, want %+v", c.spec, got, c.expected)
+		}
+	}
+}
+
+func TestParsePlatformInvalid(t *testing.T) {
+	cases := []string{
+		"", "linux/arm/v8/extra", "/amd64",
+		"linux/amd64/", "linux/", "::", ":", "windows:",
+	}
+
+	for _, spec := range cases {
+		if _, err := ParsePlatform(spec); err == nil {
+			t.Errorf("ParsePlatform(%q) expected an error, got nil", spec)
+		}
+	}
+}
+
+func TestMatcherPartialSpecs(t *testing.T) {
+	candidates := []Platform{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "linux", Arch: "arm64", Variant: "v8"},
+		{OS: "windows", Arch: "amd64", OSVersion: "10.0.17763"},
+		{OS: "windows", Arch: "amd64", OSVersion: "10.0.14393"},
+	}
+
+	cases := []struct {
+		name    string
+		spec    string
+		matches []Platform
+	}{
+		{"os only", "linux", []Platform{candidates[0], candidates[1]}},
+		{"os and arch", "linux/arm64", []Platform{candidates[1]}},
+		{"exact with variant", "linux/arm64/v8", []Platform{candidates[1]}},
+		{"osversion only windows", ":10.0.17763", []Platform{candidates[2]}},
+		{"os and osversion", "windows:10.0.14393", []Platform{candidates[3]}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := NewMatcher(c.spec)
+			if err != nil {
+				t.Fatalf("NewMatcher(%q) returned error: %v", c.spec, err)
+			}
+
+			var got []Platform
+			for _, p := range candidates {
+				if m.Match(p) {
+					got = append(got, p)
+				}
+			}
+
+			if len(got) != len(c.matches) {
+				t.Fatalf("spec %q matched %+v, want %+v", c.spec, got, c.matches)
+			}
+			for i := range got {
+				if got[i] != c.matches[i] {
+					t.Errorf("spec %q matched %+v, want %+v", c.spec, got, c.matches)
+				}
+			}
+		})
+	}
+}
+
+func TestLessOrdering(t *testing.T) {
+	platforms := []Platform{
+		{OS: "windows", Arch: "amd64"},
+		{OS: "linux", Arch: "arm", Variant: "7"},
+		{OS: "linux", Arch: "arm", Variant: "5"},
+		{OS: "linux", Arch: "amd64"},
+	}
+
+	sort.Slice(platforms, func(i, j int) bool { return Less(platforms[i], platforms[j]) })
+
+	want := []string{"linux/amd64", "linux/arm/5", "linux/arm/7", "windows/amd64"}
+	for i, p := range platforms {
+		if p.String() != want[i] {
+			t.Errorf("sorted order = %v, want %v", platformStringsOf(platforms), want)
+		}
+	}
+}
+
+func platformStringsOf(plats []Platform) []string {
+	var out []string
+	for _, p := range plats {
+		out = append(out, p.String())
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	version "github.com/hashicorp/go-version"
+)
+
+func TestPlatformStringVariant(t *testing.T) {
+	cases := []struct {
+		platform Platform
+		expected string
+	}{
+		{Platform{OS: "linux", Arch: "amd64"}, "linux/amd64"},
+		{Platform{OS: "linux", Arch: "arm", Variant: "7"}, "linux/arm/7"},
+	}
+
+	for _, c := range cases {
+		if got := c.platform.String(); got != c.expected {
+			t.Errorf("String() = %q, want %q", got, c.expected)
+		}
+	}
+}
+
+func TestExpandVariantsKeepsBaseAndAddsVariants(t *testing.T) {
+	current := version.Must(version.NewVersion("1.23"))
+
+	base := []Platform{
+		{OS: "linux", Arch: "arm", Default: true},
+		{OS: "linux", Arch: "amd64", Default: true},
+		{OS: "linux", Arch: "s390x", Default: true},
+	}
+
+	expanded := expandVariants(base, current)
+
+	var gotVariants []string
+	defaultCount := 0
+	for _, p := range expanded {
+		if p.Default {
+			defaultCount++
+		}
+		if p.Variant != "" {
+			gotVariants = append(gotVariants, p.String())
+		}
+	}
+
+	// The original entries must still be present and still default, so
+	// expansion never changes the size of the no-flag default build.
+	if defaultCount != 3 {
+		t.Errorf("expected 3 default platforms unchanged by expansion, got %d", defaultCount)
+	}
+
+	wantVariants := []string{
+		"linux/arm/5", "linux/arm/6", "linux/arm/7",
+		"linux/amd64/v1", "linux/amd64/v2", "linux/amd64/v3", "linux/amd64/v4",
+	}
+	sort.Strings(gotVariants)
+	sort.Strings(wantVariants)
+	if !reflect.DeepEqual(gotVariants, wantVariants) {
+		t.Errorf("variant entries = %v, want %v", gotVariants, wantVariants)
+	}
+
+	// Every synthesized variant entry must not be a default target.
+	for _, p := range expanded {
+		if p.Variant != "" && p.Default {
+			t.Errorf("synthesized variant platform %s must not be Default", p.String())
+		}
+	}
+}
+
+func TestExpandVariantsRespectsGoVersion(t *testing.T) {
+	old := version.Must(version.NewVersion("1.10"))
+
+	base := []Platform{{OS: "linux", Arch: "amd64", Default: true}}
+	expanded := expandVariants(base, old)
+
+	for _, p := range expanded {
+		if p.Variant != "" {
+			t.Errorf("GOAMD64 variants require Go 1.18+, got unexpected variant platform %s with Go 1.10", p.String())
+		}
+	}
+}
+
+func TestVariantEnv(t *testing.T) {
+	p := Platform{OS: "linux", Arch: "arm", Variant: "7"}
+	key, value, ok := p.VariantEnv()
+	if !ok || key != "GOARM" || value != "7" {
+		t.Errorf("VariantEnv() = (%q, %q, %v), want (GOARM, 7, true)", key, value, ok)
+	}
+
+	none := Platform{OS: "linux", Arch: "amd64"}
+	if _, _, ok := none.VariantEnv(); ok {
+		t.Errorf("VariantEnv() on a platform with no variant should return ok=false")
+	}
+}
+
+func TestPlatformEnv(t *testing.T) {
+	p := Platform{OS: "linux", Arch: "arm", Variant: "7"}
+	env := p.Env([]string{"PATH=/usr/bin"})
+
+	want := []string{"PATH=/usr/bin", "GOOS=linux", "GOARCH=arm", "GOARM=7"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("Env() = %v, want %v", env, want)
+	}
+}
+
+func TestPlatformOutputSuffix(t *testing.T) {
+	cases := []struct {
+		platform Platform
+		expected string
+	}{
+		{Platform{OS: "linux", Arch: "amd64"}, "_linux_amd64"},
+		{Platform{OS: "linux", Arch: "arm", Variant: "7"}, "_linux_arm_7"},
+	}
+
+	for _, c := range cases {
+		if got := c.platform.OutputSuffix(); got != c.expected {
+			t.Errorf("OutputSuffix() = %q, want %q", got, c.expected)
+		}
+	}
+}